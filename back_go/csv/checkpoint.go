@@ -0,0 +1,78 @@
+package csv
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// importCheckpoint is the bce_import_state row for one table: how far a
+// ResumableProcessCSV run has staged csvPath, and the fingerprint (size +
+// mtime) used to decide whether that progress still applies. Completed
+// marks that the row describes a finished import (staged and merged),
+// not in-progress work, so a later run against the same unchanged file
+// can short-circuit instead of re-resuming a pass that already finished.
+type importCheckpoint struct {
+	TableName  string
+	FilePath   string
+	FileSize   int64
+	FileMTime  time.Time
+	ByteOffset int64
+	LineCount  int64
+	Completed  bool
+}
+
+func ensureImportStateTable(db *sql.DB) error {
+	const createSQL = `CREATE TABLE IF NOT EXISTS bce_import_state (
+		table_name text PRIMARY KEY,
+		file_path text NOT NULL,
+		file_size bigint NOT NULL,
+		file_mtime timestamptz NOT NULL,
+		byte_offset bigint NOT NULL,
+		line_count bigint NOT NULL,
+		completed boolean NOT NULL DEFAULT false,
+		updated_at timestamptz NOT NULL DEFAULT now()
+	)`
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("error creating bce_import_state: %v", err)
+	}
+	return nil
+}
+
+// loadCheckpoint returns the recorded checkpoint for tableName, or nil
+// if no import has ever been started for it.
+func loadCheckpoint(db *sql.DB, tableName string) (*importCheckpoint, error) {
+	var cp importCheckpoint
+	row := db.QueryRow(`SELECT table_name, file_path, file_size, file_mtime, byte_offset, line_count, completed
+		FROM bce_import_state WHERE table_name = $1`, tableName)
+
+	err := row.Scan(&cp.TableName, &cp.FilePath, &cp.FileSize, &cp.FileMTime, &cp.ByteOffset, &cp.LineCount, &cp.Completed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading checkpoint for %s: %v", tableName, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint upserts cp, so each committed chunk advances the
+// recorded progress for its table, and a final call with Completed set
+// records that the import finished rather than being left mid-stream.
+func saveCheckpoint(db *sql.DB, cp importCheckpoint) error {
+	const upsertSQL = `INSERT INTO bce_import_state (table_name, file_path, file_size, file_mtime, byte_offset, line_count, completed, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		ON CONFLICT (table_name) DO UPDATE SET
+			file_path = EXCLUDED.file_path,
+			file_size = EXCLUDED.file_size,
+			file_mtime = EXCLUDED.file_mtime,
+			byte_offset = EXCLUDED.byte_offset,
+			line_count = EXCLUDED.line_count,
+			completed = EXCLUDED.completed,
+			updated_at = now()`
+
+	if _, err := db.Exec(upsertSQL, cp.TableName, cp.FilePath, cp.FileSize, cp.FileMTime, cp.ByteOffset, cp.LineCount, cp.Completed); err != nil {
+		return fmt.Errorf("error saving checkpoint for %s: %v", cp.TableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package csv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ParallelOptions configures ProcessCSVParallel.
+type ParallelOptions struct {
+	Workers         int
+	BatchSize       int
+	ChannelCapacity int
+	SkipHeader      bool
+	ReportInterval  time.Duration
+	Profile         TuningProfile
+}
+
+func defaultParallelOptions() ParallelOptions {
+	return ParallelOptions{
+		Workers:         4,
+		BatchSize:       5000,
+		ChannelCapacity: 8,
+		SkipHeader:      true,
+		ReportInterval:  5 * time.Second,
+	}
+}
+
+// ProcessCSVParallel drops and recreates tableName like ProcessCSVOptimized
+// but drives N concurrent pq.CopyIn workers over separate connections,
+// each committing its own row-batch, so ingest is no longer bottlenecked
+// on a single COPY stream. Unlike ProcessCSVOptimized, it creates every
+// column as TEXT and does no schema inference, schema overrides,
+// cluster-wide tuning or search indexes.
+func ProcessCSVParallel(db *sql.DB, csvPath, tableName string, opts ParallelOptions) error {
+	start := time.Now()
+
+	if opts.Workers <= 0 {
+		opts.Workers = defaultParallelOptions().Workers
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultParallelOptions().BatchSize
+	}
+	if opts.ChannelCapacity <= 0 {
+		opts.ChannelCapacity = defaultParallelOptions().ChannelCapacity
+	}
+	if opts.ReportInterval <= 0 {
+		opts.ReportInterval = defaultParallelOptions().ReportInterval
+	}
+
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return fmt.Errorf("impossible to open %s: %v", csvPath, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = ','
+
+	// The column names always come from this first row, whether or not
+	// it's actually a header: dispatchBatches below has no other source
+	// for them. SkipHeader only controls whether this row is also
+	// inserted as a data row.
+	headers, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading header: %v", err)
+	}
+
+	fmt.Printf("📄 CSV: %s\n", filepath.Base(csvPath))
+	fmt.Printf("📊 Columns: %v\n", headers)
+	fmt.Printf("⚙️ Workers: %d, batch size: %d\n", opts.Workers, opts.BatchSize)
+
+	dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
+	if _, err := db.Exec(dropSQL); err != nil {
+		return fmt.Errorf("error dropping table: %v", err)
+	}
+
+	cleanHeaders := cleanColumnNames(headers)
+	columns := make([]string, len(cleanHeaders))
+	for i, header := range cleanHeaders {
+		columns[i] = header + " TEXT"
+	}
+
+	createSQL := fmt.Sprintf("CREATE UNLOGGED TABLE %s (%s)", tableName, strings.Join(columns, ", "))
+	fmt.Printf("🏗️ Creating UNLOGGED table: %s\n", tableName)
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("error creating table: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan [][]string, opts.ChannelCapacity)
+	var rowsInserted int64
+	var firstErr error
+	var firstErrOnce sync.Once
+	setErr := func(err error) {
+		firstErrOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var workersWG sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workersWG.Add(1)
+		go func(workerID int) {
+			defer workersWG.Done()
+			if err := copyInsertWorker(ctx, db, tableName, cleanHeaders, opts.Profile, batches, &rowsInserted); err != nil {
+				setErr(fmt.Errorf("worker %d: %v", workerID, err))
+			}
+		}(i)
+	}
+
+	doneReporting := make(chan struct{})
+	go func() {
+		defer close(doneReporting)
+		ticker := time.NewTicker(opts.ReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				inserted := atomic.LoadInt64(&rowsInserted)
+				elapsed := time.Since(start)
+				fmt.Printf("🔥 COPY: %d lines (%.0f lines/sec)\n", inserted, float64(inserted)/elapsed.Seconds())
+			}
+		}
+	}()
+
+	var leadingRecords [][]string
+	if !opts.SkipHeader {
+		leadingRecords = [][]string{headers}
+	}
+
+	readErr := dispatchBatches(ctx, reader, opts.BatchSize, leadingRecords, batches)
+	close(batches)
+	workersWG.Wait()
+	cancel()
+	<-doneReporting
+
+	if readErr != nil {
+		setErr(readErr)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	lineCount := atomic.LoadInt64(&rowsInserted)
+	elapsed := time.Since(start)
+	linesPerSec := float64(lineCount) / elapsed.Seconds()
+
+	fmt.Printf("🚀 COPY: %d lines in %.2f sec (%.0f lines/sec)\n",
+		lineCount, elapsed.Seconds(), linesPerSec)
+	return nil
+}
+
+// dispatchBatches reads CSV records and pushes fixed-size batches onto the
+// channel until EOF, an error, or ctx cancellation. leadingRecords, if
+// non-empty, is inserted as data ahead of whatever reader yields - used
+// when ParallelOptions.SkipHeader is false and the row already consumed
+// to learn the column names turns out to be a data row, not a header.
+func dispatchBatches(ctx context.Context, reader *csv.Reader, batchSize int, leadingRecords [][]string, batches chan<- [][]string) error {
+	batch := make([][]string, 0, batchSize)
+	batch = append(batch, leadingRecords...)
+	lineCount := len(leadingRecords)
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch = make([][]string, 0, batchSize)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		record, err := reader.Read()
+		if err != nil {
+			if err.Error() == "EOF" {
+				break
+			}
+			return fmt.Errorf("error reading line %d: %v", lineCount+1, err)
+		}
+
+		batch = append(batch, record)
+		lineCount++
+
+		if len(batch) >= batchSize {
+			if !flush() {
+				return ctx.Err()
+			}
+		}
+	}
+
+	flush()
+	return nil
+}
+
+// copyInsertWorker pulls batches off the channel, each in its own
+// transaction over a fresh connection from the pool, until the channel
+// closes or ctx is cancelled.
+func copyInsertWorker(ctx context.Context, db *sql.DB, tableName string, headers []string, profile TuningProfile, batches <-chan [][]string, rowsInserted *int64) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case batch, ok := <-batches:
+			if !ok {
+				return nil
+			}
+			if err := copyInsertBatch(ctx, db, tableName, headers, profile, batch, rowsInserted); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func copyInsertBatch(ctx context.Context, db *sql.DB, tableName string, headers []string, profile TuningProfile, batch [][]string, rowsInserted *int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Tune this batch's transaction only, via SET LOCAL, same as the
+	// single-stream copyInsert path.
+	warnings, err := optimizeForBulkInsert(ctx, tx, profile)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Printf("⚠️ tuning: %s\n", warning)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(tableName, headers...))
+	if err != nil {
+		return fmt.Errorf("error preparing COPY: %v", err)
+	}
+
+	for _, record := range batch {
+		values := make([]interface{}, len(record))
+		for i, v := range record {
+			values[i] = v
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("error copying batch row: %v", err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("error finalizing COPY: %v", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("error closing COPY: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	atomic.AddInt64(rowsInserted, int64(len(batch)))
+	return nil
+}
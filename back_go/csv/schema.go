@@ -0,0 +1,174 @@
+package csv
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnSpec describes the inferred PostgreSQL type for a single CSV column.
+type ColumnSpec struct {
+	Name     string
+	PGType   string
+	Nullable bool
+	MaxWidth int
+}
+
+var (
+	bigintPattern  = regexp.MustCompile(`^-?\d+$`)
+	numericPattern = regexp.MustCompile(`^-?\d+\.\d+$`)
+	boolPattern    = regexp.MustCompile(`(?i)^(true|false|t|f|yes|no)$`)
+	datePattern    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// InferSchema samples the first sampleRows data rows from reader and picks
+// a PostgreSQL type per column (BIGINT, NUMERIC, BOOLEAN, DATE, TIMESTAMPTZ,
+// or TEXT, falling back to TEXT whenever a value doesn't fit a narrower
+// type). Rows already consumed from reader before calling InferSchema
+// (e.g. the header) are not part of the sample.
+//
+// Because only the sample is inspected, a column that looks narrower
+// than TEXT for every sampled row can still hold a non-conforming value
+// later in the file; callers that COPY the whole file in one transaction
+// (as ProcessCSVOptimized does) will have that later row fail the whole
+// import. Pin such columns to TEXT via a schema override, or sample more
+// rows, if the file is known to have irregular data further in.
+func InferSchema(reader *csv.Reader, headers []string, sampleRows int) ([]ColumnSpec, error) {
+	specs := make([]ColumnSpec, len(headers))
+	for i, h := range headers {
+		specs[i] = ColumnSpec{Name: h, PGType: ""}
+	}
+
+	for row := 0; row < sampleRows; row++ {
+		record, err := reader.Read()
+		if err != nil {
+			if err.Error() == "EOF" {
+				break
+			}
+			return nil, err
+		}
+
+		for i, value := range record {
+			if i >= len(specs) {
+				continue
+			}
+			if value == "" {
+				specs[i].Nullable = true
+				continue
+			}
+			if len(value) > specs[i].MaxWidth {
+				specs[i].MaxWidth = len(value)
+			}
+			specs[i].PGType = narrowType(specs[i].PGType, value)
+		}
+	}
+
+	for i := range specs {
+		if specs[i].PGType == "" {
+			specs[i].PGType = "TEXT"
+		}
+	}
+
+	return specs, nil
+}
+
+// narrowType folds a new observed value into the running type guess,
+// widening towards TEXT as soon as a value no longer fits.
+func narrowType(current, value string) string {
+	observed := typeOf(value)
+
+	if current == "" {
+		return observed
+	}
+	if current == observed {
+		return current
+	}
+	if current == "TEXT" || observed == "TEXT" {
+		return "TEXT"
+	}
+	if (current == "BIGINT" && observed == "NUMERIC") || (current == "NUMERIC" && observed == "BIGINT") {
+		return "NUMERIC"
+	}
+	return "TEXT"
+}
+
+func typeOf(value string) string {
+	switch {
+	case bigintPattern.MatchString(value):
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return "BIGINT"
+		}
+		return "TEXT"
+	case numericPattern.MatchString(value):
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return "NUMERIC"
+		}
+		return "TEXT"
+	case boolPattern.MatchString(value):
+		return "BOOLEAN"
+	case datePattern.MatchString(value):
+		if _, err := time.Parse("2006-01-02", value); err == nil {
+			return "DATE"
+		}
+		return "TEXT"
+	case isTimestamptz(value):
+		return "TIMESTAMPTZ"
+	default:
+		return "TEXT"
+	}
+}
+
+func isTimestamptz(value string) bool {
+	_, err := time.Parse(time.RFC3339, value)
+	return err == nil
+}
+
+// columnValue converts a raw CSV field to the value pq.CopyIn expects for
+// the given PostgreSQL type, returning SQL NULL instead of an empty string
+// for non-TEXT columns so the COPY doesn't fail on type coercion.
+func columnValue(pgType, raw string) interface{} {
+	if raw == "" && pgType != "TEXT" {
+		return sql.NullString{}
+	}
+	return raw
+}
+
+// buildColumnDDL renders a ColumnSpec as a "name TYPE" column definition,
+// applying any caller-supplied override.
+func buildColumnDDL(spec ColumnSpec, override map[string]string) string {
+	pgType := spec.PGType
+	if override != nil {
+		if forced, ok := override[spec.Name]; ok {
+			pgType = forced
+		}
+	}
+	return strings.Join([]string{spec.Name, pgType}, " ")
+}
+
+// cleanColumnNames lowercases CSV headers and replaces spaces/dashes with
+// underscores, so they're safe to use as unquoted PostgreSQL identifiers.
+// Shared by every ProcessCSV* variant so header cleaning stays in sync.
+func cleanColumnNames(headers []string) []string {
+	clean := make([]string, len(headers))
+	for i, header := range headers {
+		c := strings.ReplaceAll(header, " ", "_")
+		c = strings.ReplaceAll(c, "-", "_")
+		c = strings.ToLower(c)
+		clean[i] = c
+	}
+	return clean
+}
+
+// unloggedCreateTableSQL renders a "CREATE UNLOGGED TABLE" statement from
+// specs, applying any caller-supplied schema override per column.
+func unloggedCreateTableSQL(tableName string, specs []ColumnSpec, override map[string]string) string {
+	columns := make([]string, len(specs))
+	for i, spec := range specs {
+		columns[i] = buildColumnDDL(spec, override)
+	}
+	return fmt.Sprintf("CREATE UNLOGGED TABLE %s (%s)", tableName, strings.Join(columns, ", "))
+}
@@ -0,0 +1,122 @@
+package csv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TuningProfile selects which session-scoped parameters are applied to
+// the COPY transaction. Cluster-wide settings (fsync, full_page_writes)
+// are never part of a profile; they're only reachable through
+// AllowClusterUnsafe.
+type TuningProfile int
+
+const (
+	// ProfileSafe only relaxes client_min_messages so COPY progress
+	// doesn't get buried in NOTICE spam. Safe on a shared cluster.
+	ProfileSafe TuningProfile = iota
+	// ProfileFastImport additionally relaxes durability and memory
+	// limits for the duration of the COPY transaction, via SET LOCAL,
+	// so nothing leaks past commit.
+	ProfileFastImport
+	// ProfileUnsafe is ProfileFastImport plus permission to touch
+	// cluster-wide settings through AllowClusterUnsafe.
+	ProfileUnsafe
+)
+
+// sessionTunables are parameters that are genuinely session-scoped on
+// PG 9.5+ and safe to SET LOCAL inside the COPY transaction.
+// shared_buffers, wal_buffers, and checkpoint_segments are server-level
+// (require a restart or were removed outright) and are deliberately
+// left out: issuing SET for them either errors or silently no-ops.
+var sessionTunables = map[TuningProfile]map[string]string{
+	ProfileSafe: {
+		"client_min_messages": "warning",
+	},
+	ProfileFastImport: {
+		"client_min_messages":  "warning",
+		"synchronous_commit":   "off",
+		"work_mem":             "512MB",
+		"maintenance_work_mem": "1GB",
+		"temp_buffers":         "64MB",
+	},
+}
+
+func init() {
+	sessionTunables[ProfileUnsafe] = sessionTunables[ProfileFastImport]
+}
+
+// optimizeForBulkInsert applies profile's session tunables as SET LOCAL
+// inside tx, so they're scoped to the COPY transaction and never leak
+// onto the shared connection pool. A parameter Postgres rejects (e.g.
+// removed in this server version) is reported as a warning rather than
+// failing the whole import.
+func optimizeForBulkInsert(ctx context.Context, tx *sql.Tx, profile TuningProfile) ([]string, error) {
+	var warnings []string
+
+	for name, value := range sessionTunables[profile] {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT tuning_%s", name)); err != nil {
+			return warnings, fmt.Errorf("error creating savepoint for %s: %v", name, err)
+		}
+
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL %s = %s", name, quoteSetting(value)))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s not applied: %v", name, err))
+			if _, rbErr := tx.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT tuning_%s", name)); rbErr != nil {
+				return warnings, fmt.Errorf("error rolling back savepoint for %s: %v", name, rbErr)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("RELEASE SAVEPOINT tuning_%s", name)); err != nil {
+			return warnings, fmt.Errorf("error releasing savepoint for %s: %v", name, err)
+		}
+	}
+
+	return warnings, nil
+}
+
+func quoteSetting(value string) string {
+	return "'" + value + "'"
+}
+
+// ClusterUnsafeRestore undoes the cluster-wide settings enabled by
+// EnableClusterUnsafeTuning, reloading the config again once done.
+type ClusterUnsafeRestore func(ctx context.Context) error
+
+// EnableClusterUnsafeTuning flips fsync and full_page_writes off at the
+// cluster level via ALTER SYSTEM + pg_reload_conf(), for callers that
+// explicitly opted in with AllowClusterUnsafe. The returned restore func
+// must be deferred to put both settings back and reload again; it is
+// never called automatically because this changes behavior for every
+// other connection to the cluster, not just this import.
+func EnableClusterUnsafeTuning(ctx context.Context, db *sql.DB) (ClusterUnsafeRestore, error) {
+	unsafeSettings := map[string]string{
+		"fsync":            "off",
+		"full_page_writes": "off",
+	}
+
+	for name, value := range unsafeSettings {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SYSTEM SET %s = %s", name, value)); err != nil {
+			return nil, fmt.Errorf("error setting cluster-unsafe %s: %v", name, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "SELECT pg_reload_conf()"); err != nil {
+		return nil, fmt.Errorf("error reloading config: %v", err)
+	}
+
+	restore := func(ctx context.Context) error {
+		for name := range unsafeSettings {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SYSTEM RESET %s", name)); err != nil {
+				return fmt.Errorf("error restoring %s: %v", name, err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, "SELECT pg_reload_conf()"); err != nil {
+			return fmt.Errorf("error reloading config: %v", err)
+		}
+		return nil
+	}
+
+	return restore, nil
+}
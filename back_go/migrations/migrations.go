@@ -0,0 +1,280 @@
+// Package migrations versions the schema of imported BCE tables. Each
+// migration is a pair of numbered SQL files (NNN_name.up.sql /
+// NNN_name.down.sql) discovered from a directory; applied versions are
+// recorded per-namespace in a schema_migrations table so a pipeline can
+// be re-run idempotently instead of dropping and recreating tables from
+// scratch, and so unrelated migration sets (e.g. one generated per
+// imported table) don't collide on the same version numbers.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a single numbered schema step, with both directions loaded
+// from disk so Up/Down/Steps can replay either one.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+var filenamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migrator applies and tracks migrations discovered from a directory
+// against a single *sql.DB, under a namespace. Versions are only ever
+// compared within the same namespace, so two unrelated migration sets
+// (e.g. one per imported table) numbering their own files from 001 don't
+// collide in the shared schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dir        string
+	namespace  string
+	migrations []Migration
+}
+
+// New discovers migrations in dir and ensures the schema_migrations
+// bookkeeping table exists. namespace scopes the recorded versions -
+// pass a fixed value (e.g. "bce") for a single global migration history,
+// or something per-caller (e.g. a table name) when each caller generates
+// its own independent, differently-versioned migration set.
+func New(db *sql.DB, dir, namespace string) (*Migrator, error) {
+	migs, err := discover(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering migrations in %s: %v", dir, err)
+	}
+
+	m := &Migrator{db: db, dir: dir, namespace: namespace, migrations: migs}
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Apply discovers migrations in dir and brings db up to the latest
+// version within namespace.
+func Apply(db *sql.DB, dir, namespace string) error {
+	m, err := New(db, dir, namespace)
+	if err != nil {
+		return err
+	}
+	return m.Up()
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	const createSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		namespace text NOT NULL,
+		version bigint NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (namespace, version)
+	)`
+	if _, err := m.db.Exec(createSQL); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+	return nil
+}
+
+func (m *Migrator) currentVersion() (int64, error) {
+	var version sql.NullInt64
+	row := m.db.QueryRow("SELECT max(version) FROM schema_migrations WHERE namespace = $1", m.namespace)
+	if err := row.Scan(&version); err != nil {
+		return 0, fmt.Errorf("error reading current version: %v", err)
+	}
+	if !version.Valid {
+		return 0, nil
+	}
+	return version.Int64, nil
+}
+
+// Up applies every migration newer than the current version, in order.
+func (m *Migrator) Up() error {
+	current, err := m.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version <= current {
+			continue
+		}
+		if err := m.runAndRecord(mig, mig.UpSQL, mig.Version, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts every applied migration, from the most recent down to the
+// first, undoing the entire history.
+func (m *Migrator) Down() error {
+	current, err := m.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version > current {
+			continue
+		}
+		if err := m.runAndRecord(mig, mig.DownSQL, mig.Version, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Steps applies (n > 0) or reverts (n < 0) up to |n| migrations relative
+// to the current version.
+func (m *Migrator) Steps(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	current, err := m.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		applied := 0
+		for _, mig := range m.migrations {
+			if applied >= n {
+				break
+			}
+			if mig.Version <= current {
+				continue
+			}
+			if err := m.runAndRecord(mig, mig.UpSQL, mig.Version, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	}
+
+	reverted := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if reverted >= -n {
+			break
+		}
+		mig := m.migrations[i]
+		if mig.Version > current {
+			continue
+		}
+		if err := m.runAndRecord(mig, mig.DownSQL, mig.Version, false); err != nil {
+			return err
+		}
+		reverted++
+	}
+	return nil
+}
+
+// Force sets the recorded version for this namespace to v without
+// running any SQL - for recovering from a migration that partially
+// applied outside a transaction boundary, or for a caller whose
+// migration set describes state that's already known to have been
+// recreated from scratch (v = 0 makes the next Up() reapply everything).
+func (m *Migrator) Force(v int64) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE namespace = $1 AND version > $2", m.namespace, v); err != nil {
+		return fmt.Errorf("error forcing version: %v", err)
+	}
+	if v > 0 {
+		if _, err := tx.Exec("INSERT INTO schema_migrations (namespace, version) VALUES ($1, $2) ON CONFLICT DO NOTHING", m.namespace, v); err != nil {
+			return fmt.Errorf("error forcing version: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) runAndRecord(mig Migration, sqlText string, version int64, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(sqlText) != "" {
+		if _, err := tx.Exec(sqlText); err != nil {
+			return fmt.Errorf("error applying migration %d (%s): %v", version, mig.Name, err)
+		}
+	}
+
+	if up {
+		if _, err := tx.Exec("INSERT INTO schema_migrations (namespace, version) VALUES ($1, $2)", m.namespace, version); err != nil {
+			return fmt.Errorf("error recording migration %d: %v", version, err)
+		}
+	} else {
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE namespace = $1 AND version = $2", m.namespace, version); err != nil {
+			return fmt.Errorf("error unrecording migration %d: %v", version, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// discover reads dir for NNN_name.up.sql / NNN_name.down.sql pairs and
+// returns them sorted by version.
+func discover(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := filenamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), err)
+		}
+		name := match[2]
+		direction := match[3]
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if direction == "up" {
+			mig.UpSQL = string(content)
+		} else {
+			mig.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}